@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	goruntime "runtime"
+	"sync"
 
+	"vadcondense/internal/live"
 	"vadcondense/internal/vad"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -14,6 +17,13 @@ import (
 // App struct
 type App struct {
 	ctx context.Context
+
+	liveMu      sync.Mutex
+	liveSession *live.Session
+
+	analyzeMu      sync.Mutex
+	analyzePath    string
+	analyzeSamples []float32
 }
 
 // NewApp creates a new App application struct
@@ -34,25 +44,72 @@ func (a *App) Greet(name string) string {
 
 // CondenseProgress represents progress updates sent to the frontend via events
 type CondenseProgress struct {
-	FilePath string `json:"filePath"`
-	Status   string `json:"status"`
-	Error    string `json:"error,omitempty"`
+	FilePath string  `json:"filePath"`
+	Status   string  `json:"status"`
+	Stage    string  `json:"stage,omitempty"`
+	Fraction float64 `json:"fraction,omitempty"`
+	Error    string  `json:"error,omitempty"`
 }
 
 // CondenseOptions contains all configurable settings for audio processing
 type CondenseOptions struct {
 	OutputSuffix       string  `json:"outputSuffix"`
 	OutputDir          string  `json:"outputDir"`
-	OutputFormat       string  `json:"outputFormat"`
 	VadThreshold       float64 `json:"vadThreshold"`
 	MinSilenceDuration int     `json:"minSilenceDuration"`
 	SpeechPaddingMs    int     `json:"speechPaddingMs"`
+
+	// Encoder is the ffmpeg codec to use for export (e.g. "libmp3lame",
+	// "libopus", "aac", "flac", "pcm_s16le"). Leave empty to use ffmpeg's
+	// default codec for Container.
+	Encoder    string `json:"encoder"`
+	UseBitrate bool   `json:"useBitrate"`
+	Bitrate    string `json:"bitrate"`
+	UseQuality bool   `json:"useQuality"`
+	Quality    string `json:"quality"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+	Container  string `json:"container"`
+
+	// SegmentsFormat, if set ("json", "csv", "srt", or "edl"), writes the
+	// detected speech segments as a sidecar file next to the condensed
+	// audio, named <basename><SegmentsSuffix>.<SegmentsFormat>.
+	SegmentsFormat string `json:"segmentsFormat"`
+	SegmentsSuffix string `json:"segmentsSuffix"`
+
+	// Concurrency caps how many files CondenseFiles processes in parallel.
+	// 0 (the default) uses GOMAXPROCS.
+	Concurrency int `json:"concurrency"`
+}
+
+// encodeConfig builds the vad.EncodeConfig ffmpeg needs from the
+// export-related fields of o.
+func (o CondenseOptions) encodeConfig() vad.EncodeConfig {
+	return vad.EncodeConfig{
+		Encoder:    o.Encoder,
+		UseBitrate: o.UseBitrate,
+		Bitrate:    o.Bitrate,
+		UseQuality: o.UseQuality,
+		Quality:    o.Quality,
+		SampleRate: o.SampleRate,
+		Channels:   o.Channels,
+		Container:  o.Container,
+	}
 }
 
-// CondenseFiles processes multiple audio files and removes silence from each.
+// CondenseFiles processes multiple audio files and removes silence from
+// each, running up to options.Concurrency of them at once so a batch of
+// long files can't pile up decoding serially.
 func (a *App) CondenseFiles(filePaths []string, options CondenseOptions) {
 	slog.Info("Starting batch condense", "fileCount", len(filePaths))
 
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = goruntime.GOMAXPROCS(0)
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
 	for i, filePath := range filePaths {
 		slog.Debug("Processing file", "index", i+1, "total", len(filePaths), "file", filePath)
 
@@ -62,42 +119,173 @@ func (a *App) CondenseFiles(filePaths []string, options CondenseOptions) {
 			Status:   "pending",
 		})
 
-		// Status callback emits events for each stage
-		statusCallback := func(status string) {
-			runtime.EventsEmit(a.ctx, "condense:progress", CondenseProgress{
-				FilePath: filePath,
-				Status:   status,
-			})
-		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(filePath string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			a.condenseFile(filePath, options)
+		}(filePath)
+	}
+	wg.Wait()
 
-		err := vad.CondenseWithOptions(
-			filePath,
-			options.OutputDir,
-			options.OutputSuffix,
-			options.OutputFormat,
-			float32(options.VadThreshold),
-			options.MinSilenceDuration,
-			options.SpeechPaddingMs,
-			nil, // progressCallback (silent for now)
-			statusCallback,
-		)
-
-		// Emit completion event for this file
-		status := "completed"
-		errMsg := ""
-		if err != nil {
-			status = "error"
-			errMsg = err.Error()
-			slog.Error("File processing failed", "file", filePath, "error", err)
-		}
+	slog.Info("Batch condense complete", "fileCount", len(filePaths))
+}
+
+// condenseFile runs vad.CondenseWithOptions for a single file, emitting
+// progress and completion events for it.
+func (a *App) condenseFile(filePath string, options CondenseOptions) {
+	// Status callback emits events for each stage
+	statusCallback := func(status string) {
 		runtime.EventsEmit(a.ctx, "condense:progress", CondenseProgress{
 			FilePath: filePath,
 			Status:   status,
-			Error:    errMsg,
 		})
 	}
 
-	slog.Info("Batch condense complete", "fileCount", len(filePaths))
+	// Progress callback emits fine-grained, per-stage fraction updates
+	progressCallback := func(update vad.ProgressUpdate) {
+		runtime.EventsEmit(a.ctx, "condense:progress", CondenseProgress{
+			FilePath: filePath,
+			Stage:    update.Stage,
+			Fraction: update.Fraction,
+		})
+	}
+
+	err := vad.CondenseWithOptions(
+		filePath,
+		options.OutputDir,
+		options.OutputSuffix,
+		options.encodeConfig(),
+		options.SegmentsFormat,
+		options.SegmentsSuffix,
+		float32(options.VadThreshold),
+		options.MinSilenceDuration,
+		options.SpeechPaddingMs,
+		progressCallback,
+		statusCallback,
+	)
+
+	// Emit completion event for this file
+	status := "completed"
+	errMsg := ""
+	if err != nil {
+		status = "error"
+		errMsg = err.Error()
+		slog.Error("File processing failed", "file", filePath, "error", err)
+	}
+	runtime.EventsEmit(a.ctx, "condense:progress", CondenseProgress{
+		FilePath: filePath,
+		Status:   status,
+		Error:    errMsg,
+	})
+}
+
+// AnalyzeResult carries the waveform peaks and detected speech segments for
+// a single file, so the UI can draw a preview before the user exports.
+type AnalyzeResult struct {
+	Peaks    []vad.Peak    `json:"peaks"`
+	Segments []vad.Segment `json:"segments"`
+}
+
+// AnalyzeFile decodes path and runs speech detection on it with the given
+// threshold, minSilenceDuration and padMs, returning waveform peaks (binned
+// into numBins) and the detected speech segments. It does not write any
+// output file, so the UI can offer a live preview before the user commits to
+// condensing.
+//
+// The decoded samples of the most recently analyzed path are cached, so
+// consecutive calls for the same file (e.g. the UI re-running detection as
+// the user tunes threshold) only decode with ffmpeg once; analyzing a
+// different file replaces the cache.
+func (a *App) AnalyzeFile(path string, numBins int, threshold float64, minSilenceDuration, padMs int) (AnalyzeResult, error) {
+	a.analyzeMu.Lock()
+	defer a.analyzeMu.Unlock()
+
+	var segments []vad.Segment
+	if a.analyzePath == path {
+		var err error
+		segments, err = vad.DetectSegmentsFromSamples(a.analyzeSamples, float32(threshold), minSilenceDuration, padMs)
+		if err != nil {
+			return AnalyzeResult{}, fmt.Errorf("failed to analyze %s: %w", path, err)
+		}
+	} else {
+		samples, segs, err := vad.DetectSegments(path, float32(threshold), minSilenceDuration, padMs, nil)
+		if err != nil {
+			return AnalyzeResult{}, fmt.Errorf("failed to analyze %s: %w", path, err)
+		}
+		a.analyzePath = path
+		a.analyzeSamples = samples
+		segments = segs
+	}
+
+	return AnalyzeResult{
+		Peaks:    vad.ComputePeaks(a.analyzeSamples, numBins),
+		Segments: segments,
+	}, nil
+}
+
+// StartLiveCapture opens deviceName (or the system default input device,
+// if deviceName is empty) and starts streaming "live:speech-start" and
+// "live:speech-end" events to the frontend as speech is detected. If
+// options.OutputDir is set, it also records the raw capture and the
+// condensed (silence-removed) capture to disk. Only one live capture can
+// run at a time; call StopLiveCapture to end it.
+func (a *App) StartLiveCapture(deviceName string, options CondenseOptions) error {
+	a.liveMu.Lock()
+	defer a.liveMu.Unlock()
+
+	if a.liveSession != nil {
+		return fmt.Errorf("live capture is already running")
+	}
+
+	cfg := live.Config{
+		DeviceName:           deviceName,
+		Threshold:            float32(options.VadThreshold),
+		MinSilenceDurationMs: options.MinSilenceDuration,
+		SpeechPadMs:          options.SpeechPaddingMs,
+	}
+	if options.OutputDir != "" {
+		ext := options.Container
+		if ext == "" {
+			ext = "wav"
+		}
+		cfg.RawOutputPath = filepath.Join(options.OutputDir, "live_raw."+ext)
+		cfg.CondensedOutputPath = filepath.Join(options.OutputDir, "live"+options.OutputSuffix+"."+ext)
+	}
+
+	session, err := live.Start(cfg, func(event live.SpeechEvent) {
+		runtime.EventsEmit(a.ctx, "live:"+event.Type, event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start live capture: %w", err)
+	}
+
+	a.liveSession = session
+	return nil
+}
+
+// StopLiveCapture ends the live capture session started by
+// StartLiveCapture, finalizing any recordings.
+func (a *App) StopLiveCapture() error {
+	a.liveMu.Lock()
+	defer a.liveMu.Unlock()
+
+	if a.liveSession == nil {
+		return fmt.Errorf("no live capture is running")
+	}
+
+	err := a.liveSession.Stop()
+	a.liveSession = nil
+	if err != nil {
+		return fmt.Errorf("failed to stop live capture: %w", err)
+	}
+	return nil
+}
+
+// ListAudioInputDevices returns the microphones available for StartLiveCapture.
+func (a *App) ListAudioInputDevices() ([]live.DeviceInfo, error) {
+	return live.ListInputDevices()
 }
 
 // BrowseFiles opens a native file dialog for selecting audio files
@@ -14,32 +14,42 @@ import (
 	"github.com/spf13/pflag"
 )
 
-func printProgress(p float64) {
-	pct := int(p * 100)
+func printProgress(u vad.ProgressUpdate) {
+	pct := int(u.Fraction * 100)
 	const width = 40
-	filled := int(float64(width) * p)
+	filled := int(float64(width) * u.Fraction)
 	if filled > width {
 		filled = width
 	}
 	bar := strings.Repeat("█", filled) + strings.Repeat(" ", width-filled)
 
-	fmt.Printf("\rvoice detection [%s] %3d%%", bar, pct)
-	if p >= 1 {
+	fmt.Printf("\r%-7s [%s] %3d%%", u.Stage, bar, pct)
+	if u.Fraction >= 1 {
 		fmt.Println()
 	}
 }
 
 func main() {
-	var outDir, outSuffix, outFormat, thresholdStr string
+	var outDir, outSuffix, container, thresholdStr string
+	var encoder, bitrate, quality string
+	var sampleRate, channels int
 	var minSilenceDuration, padMs int
+	var segmentsFormat, segmentsSuffix string
 	var verbose bool
 
 	pflag.StringVarP(&outDir, "output-dir", "o", ".", "Output directory")
 	pflag.StringVarP(&outSuffix, "suffix", "s", "_condensed", "Output file suffix")
-	pflag.StringVarP(&outFormat, "format", "f", "wav", "Output file format")
+	pflag.StringVarP(&container, "format", "f", "wav", "Output container/extension")
+	pflag.StringVar(&encoder, "encoder", "", "ffmpeg audio codec (e.g. libmp3lame, libopus, aac, flac, pcm_s16le); default is ffmpeg's default for --format")
+	pflag.StringVar(&bitrate, "bitrate", "", "Constant bitrate for the encoder (e.g. 192k)")
+	pflag.StringVar(&quality, "quality", "", "Variable quality/VBR level for the encoder (e.g. 2 for libmp3lame, 5 for libvorbis)")
+	pflag.IntVar(&sampleRate, "sample-rate", 0, "Output sample rate in Hz; 0 keeps ffmpeg's default")
+	pflag.IntVar(&channels, "channels", 0, "Output channel count; 0 keeps ffmpeg's default")
 	pflag.StringVarP(&thresholdStr, "threshold", "t", "0.3", "Silence threshold")
 	pflag.IntVarP(&minSilenceDuration, "min-silence-duration", "m", 200, "Minimum silence duration in ms")
 	pflag.IntVarP(&padMs, "pad-ms", "p", 200, "Padding in milliseconds")
+	pflag.StringVar(&segmentsFormat, "segments", "", "Also write detected speech segments as a sidecar file (json, csv, srt, or edl)")
+	pflag.StringVar(&segmentsSuffix, "segments-suffix", "_segments", "Suffix for the segments sidecar filename")
 	pflag.BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 
 	pflag.Parse()
@@ -64,6 +74,17 @@ func main() {
 		log.Fatalf("failed to parse threshold: %s", err)
 	}
 
+	encodeConfig := vad.EncodeConfig{
+		Encoder:    encoder,
+		UseBitrate: bitrate != "",
+		Bitrate:    bitrate,
+		UseQuality: quality != "",
+		Quality:    quality,
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Container:  container,
+	}
+
 	for i, inPath := range inputs {
 		fmt.Printf("(%d/%d) Condensing %s\n", i+1, len(inputs), inPath)
 
@@ -76,14 +97,17 @@ func main() {
 			inPath,
 			outDir,
 			outSuffix,
-			outFormat,
+			encodeConfig,
+			segmentsFormat,
+			segmentsSuffix,
 			float32(threshold),
 			minSilenceDuration,
 			padMs,
-			func(p float64) {
+			func(u vad.ProgressUpdate) {
 				loadingSpinner.Stop()
-				printProgress(p)
+				printProgress(u)
 			},
+			nil,
 		)
 
 		loadingSpinner.Stop()
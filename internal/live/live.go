@@ -0,0 +1,444 @@
+// Package live captures audio from a microphone and runs incremental
+// speech detection over it in real time, emitting speech-start/speech-end
+// transitions as they happen.
+package live
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"sync"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/jjsteffen/silero-vad-go/speech"
+)
+
+// frameRingSize bounds how many captured frames can queue up waiting for
+// detection before the capture callback starts dropping them. The callback
+// itself never blocks on a full ring.
+const frameRingSize = 64
+
+// detectWindowSeconds is how much captured audio accumulates before a
+// detection pass runs. A single captured callback buffer (32ms at the
+// default FramesPerBuffer) can't hold the silence duration the VAD needs to
+// close a segment, so frames are batched into a window first, mirroring the
+// 30s chunking streamDecode uses for exports, just sized for sub-second live
+// latency instead of bounding memory on a long file.
+const detectWindowSeconds = 0.5
+
+var (
+	initMu    sync.Mutex
+	initCount int
+)
+
+// ref increments the portaudio init refcount, calling portaudio.Initialize
+// the first time anything needs it.
+func ref() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initCount == 0 {
+		if err := portaudio.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize portaudio: %w", err)
+		}
+	}
+	initCount++
+	return nil
+}
+
+// unref decrements the portaudio init refcount, calling portaudio.Terminate
+// once nothing else is using it.
+func unref() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initCount == 0 {
+		return nil
+	}
+	initCount--
+	if initCount == 0 {
+		if err := portaudio.Terminate(); err != nil {
+			return fmt.Errorf("failed to terminate portaudio: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeviceInfo describes an available audio input device.
+type DeviceInfo struct {
+	Name             string `json:"name"`
+	MaxInputChannels int    `json:"maxInputChannels"`
+	IsDefault        bool   `json:"isDefault"`
+}
+
+// ListInputDevices returns the audio input devices portaudio can see.
+func ListInputDevices() ([]DeviceInfo, error) {
+	if err := ref(); err != nil {
+		return nil, err
+	}
+	defer unref()
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio devices: %w", err)
+	}
+
+	defaultDevice, err := portaudio.DefaultInputDevice()
+	if err != nil {
+		defaultDevice = nil
+	}
+
+	var inputs []DeviceInfo
+	for _, d := range devices {
+		if d.MaxInputChannels <= 0 {
+			continue
+		}
+		inputs = append(inputs, DeviceInfo{
+			Name:             d.Name,
+			MaxInputChannels: d.MaxInputChannels,
+			IsDefault:        defaultDevice != nil && d.Name == defaultDevice.Name,
+		})
+	}
+	return inputs, nil
+}
+
+// Config controls a live capture and speech-detection session.
+type Config struct {
+	DeviceName      string  // empty uses the system default input device
+	SampleRate      float64 // Hz; 0 defaults to 16000, what the VAD model expects
+	FramesPerBuffer int     // samples per captured frame; 0 defaults to 512
+
+	Threshold            float32
+	MinSilenceDurationMs int
+	SpeechPadMs          int
+
+	// RawOutputPath, if set, records the full captured stream to disk.
+	RawOutputPath string
+	// CondensedOutputPath, if set, records only the in-speech audio.
+	CondensedOutputPath string
+}
+
+// SpeechEvent reports a speech-start or speech-end transition detected
+// during live capture, timestamped against the moment capture began.
+type SpeechEvent struct {
+	Type  string  `json:"type"` // "speech-start" or "speech-end"
+	AtSec float64 `json:"atSec"`
+}
+
+// SpeechEventCallback is invoked on every speech-start/speech-end
+// transition detected during a live capture Session.
+type SpeechEventCallback func(SpeechEvent)
+
+// Session is a running live capture and speech-detection pipeline started
+// by Start. Call Stop to end it.
+type Session struct {
+	cfg      Config
+	detector *speech.Detector
+	onEvent  SpeechEventCallback
+
+	stream *portaudio.Stream
+	frames chan []float32
+	wg     sync.WaitGroup
+
+	speaking bool
+	elapsed  float64
+
+	rawCmd         *exec.Cmd
+	rawStdin       io.WriteCloser
+	condensedCmd   *exec.Cmd
+	condensedStdin io.WriteCloser
+}
+
+// Start begins capturing from the named input device (or the system
+// default, if cfg.DeviceName is empty) and running incremental speech
+// detection over it, invoking onEvent on every speech-start/speech-end
+// transition. The capture callback never blocks: frames are buffered into
+// a ring and detected on a worker goroutine, so a slow detector drops
+// frames rather than stalling the audio driver. Call (*Session).Stop to
+// end capture.
+func Start(cfg Config, onEvent SpeechEventCallback) (*Session, error) {
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = 16000
+	}
+	if cfg.FramesPerBuffer == 0 {
+		cfg.FramesPerBuffer = 512
+	}
+	if onEvent == nil {
+		onEvent = func(SpeechEvent) {}
+	}
+
+	if err := ref(); err != nil {
+		return nil, err
+	}
+
+	sd, err := speech.NewDetector(speech.DetectorConfig{
+		ModelPath:            "./silero_vad.onnx",
+		SampleRate:           int(cfg.SampleRate),
+		Threshold:            cfg.Threshold,
+		MinSilenceDurationMs: cfg.MinSilenceDurationMs,
+		SpeechPadMs:          cfg.SpeechPadMs,
+	})
+	if err != nil {
+		_ = unref()
+		return nil, fmt.Errorf("failed to create speech detector: %w", err)
+	}
+
+	s := &Session{
+		cfg:      cfg,
+		detector: sd,
+		onEvent:  onEvent,
+		frames:   make(chan []float32, frameRingSize),
+	}
+
+	if cfg.RawOutputPath != "" {
+		if s.rawCmd, s.rawStdin, err = startEncoder(cfg.RawOutputPath, cfg.SampleRate); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+	}
+	if cfg.CondensedOutputPath != "" {
+		if s.condensedCmd, s.condensedStdin, err = startEncoder(cfg.CondensedOutputPath, cfg.SampleRate); err != nil {
+			s.cleanup()
+			return nil, err
+		}
+	}
+
+	stream, err := openStream(cfg.DeviceName, cfg.SampleRate, cfg.FramesPerBuffer, s.onAudio)
+	if err != nil {
+		s.cleanup()
+		return nil, err
+	}
+	s.stream = stream
+
+	if err := stream.Start(); err != nil {
+		s.cleanup()
+		return nil, fmt.Errorf("failed to start audio stream: %w", err)
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Stop ends capture, waits for the detector to drain, closes any recording
+// encoders, and releases portaudio if nothing else is using it.
+func (s *Session) Stop() error {
+	var errs []error
+
+	if s.stream != nil {
+		if err := s.stream.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop audio stream: %w", err))
+		}
+	}
+
+	// Safe to close here: portaudio guarantees the capture callback won't
+	// fire again once Stream.Stop has returned.
+	close(s.frames)
+	s.wg.Wait()
+
+	if s.stream != nil {
+		if err := s.stream.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close audio stream: %w", err))
+		}
+	}
+
+	s.detector.Destroy()
+
+	if err := closeEncoder(s.rawStdin, s.rawCmd); err != nil {
+		errs = append(errs, err)
+	}
+	if err := closeEncoder(s.condensedStdin, s.condensedCmd); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := unref(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// cleanup releases whatever partial state Start had acquired before
+// failing, so a failed Start doesn't leak the portaudio refcount or
+// encoder subprocesses.
+func (s *Session) cleanup() {
+	_ = closeEncoder(s.rawStdin, s.rawCmd)
+	_ = closeEncoder(s.condensedStdin, s.condensedCmd)
+	if s.detector != nil {
+		s.detector.Destroy()
+	}
+	_ = unref()
+}
+
+// onAudio is the portaudio capture callback. It must never block: frames
+// are copied (portaudio reuses the backing buffer) and handed to the
+// worker goroutine over a buffered channel, dropping the frame if the
+// detector is falling behind.
+func (s *Session) onAudio(in []float32) {
+	frame := make([]float32, len(in))
+	copy(frame, in)
+
+	select {
+	case s.frames <- frame:
+	default:
+		slog.Warn("live capture frame dropped, detector is falling behind")
+	}
+}
+
+// run is the worker goroutine that drains captured frames, accumulates them
+// into detectWindowSeconds-sized windows, runs incremental speech detection
+// over each window, and forwards audio to the recording encoders.
+func (s *Session) run() {
+	defer s.wg.Done()
+
+	windowSamples := int(s.cfg.SampleRate * detectWindowSeconds)
+	var window []float32
+
+	flush := func() {
+		if len(window) == 0 {
+			return
+		}
+		wasSpeaking := s.speaking
+		s.processWindow(window)
+		if s.condensedStdin != nil && (wasSpeaking || s.speaking) {
+			if err := writeFrame(s.condensedStdin, window); err != nil {
+				slog.Warn("failed to write condensed capture frame", "error", err)
+			}
+		}
+		window = nil
+	}
+
+	for frame := range s.frames {
+		if s.rawStdin != nil {
+			if err := writeFrame(s.rawStdin, frame); err != nil {
+				slog.Warn("failed to write raw capture frame", "error", err)
+			}
+		}
+
+		window = append(window, frame...)
+		if len(window) >= windowSamples {
+			flush()
+		}
+	}
+	// Flush whatever's left of the last, possibly short, window so the
+	// final moments of capture aren't dropped.
+	flush()
+}
+
+// processWindow runs the detector over an accumulated window of captured
+// audio and emits a speech-start/speech-end event on every transition,
+// tracking in-speech state across windows since each window is detected
+// independently.
+func (s *Session) processWindow(window []float32) {
+	windowDuration := float64(len(window)) / s.cfg.SampleRate
+
+	segments, err := s.detector.Detect(window)
+	if err != nil {
+		slog.Error("live speech detection failed", "error", err)
+		s.elapsed += windowDuration
+		return
+	}
+
+	if len(segments) > 0 && !s.speaking {
+		s.speaking = true
+		s.onEvent(SpeechEvent{Type: "speech-start", AtSec: s.elapsed + segments[0].SpeechStartAt})
+	}
+
+	if s.speaking {
+		last := windowDuration
+		stillSpeaking := len(segments) > 0 && segments[len(segments)-1].SpeechEndAt >= windowDuration
+		if !stillSpeaking {
+			if len(segments) > 0 {
+				last = segments[len(segments)-1].SpeechEndAt
+			}
+			s.speaking = false
+			s.onEvent(SpeechEvent{Type: "speech-end", AtSec: s.elapsed + last})
+		}
+	}
+
+	s.elapsed += windowDuration
+}
+
+// openStream opens a mono portaudio input stream at sampleRate against the
+// named device, or the system default device if deviceName is empty.
+func openStream(deviceName string, sampleRate float64, framesPerBuffer int, callback func([]float32)) (*portaudio.Stream, error) {
+	if deviceName == "" {
+		stream, err := portaudio.OpenDefaultStream(1, 0, sampleRate, framesPerBuffer, callback)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open default audio input stream: %w", err)
+		}
+		return stream, nil
+	}
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio devices: %w", err)
+	}
+
+	for _, d := range devices {
+		if d.Name != deviceName || d.MaxInputChannels <= 0 {
+			continue
+		}
+		params := portaudio.LowLatencyParameters(d, nil)
+		params.Input.Channels = 1
+		params.SampleRate = sampleRate
+		params.FramesPerBuffer = framesPerBuffer
+
+		stream, err := portaudio.OpenStream(params, callback)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audio input stream on %q: %w", deviceName, err)
+		}
+		return stream, nil
+	}
+
+	return nil, fmt.Errorf("no audio input device named %q", deviceName)
+}
+
+// startEncoder pipes raw f32le mono PCM written to the returned stdin into
+// an ffmpeg process that encodes it to path.
+func startEncoder(path string, sampleRate float64) (*exec.Cmd, io.WriteCloser, error) {
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-f", "f32le",
+		"-ar", fmt.Sprintf("%d", int(sampleRate)),
+		"-ac", "1",
+		"-i", "pipe:0",
+		path,
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open ffmpeg stdin pipe for %s: %w", path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start ffmpeg encoder for %s: %w", path, err)
+	}
+	return cmd, stdin, nil
+}
+
+// closeEncoder closes an encoder's stdin (signaling ffmpeg to finish) and
+// waits for it to exit. It's a no-op if the encoder was never started.
+func closeEncoder(stdin io.WriteCloser, cmd *exec.Cmd) error {
+	if stdin == nil {
+		return nil
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg encoder error: %w", err)
+	}
+	return nil
+}
+
+// writeFrame writes a frame of float32 samples to w as little-endian f32le
+// PCM bytes.
+func writeFrame(w io.Writer, frame []float32) error {
+	buf := make([]byte, len(frame)*4)
+	for i, sample := range frame {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(sample))
+	}
+	_, err := w.Write(buf)
+	return err
+}
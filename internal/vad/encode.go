@@ -0,0 +1,92 @@
+package vad
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EncodeConfig controls how CondenseWithOptions encodes the exported,
+// condensed audio file. Only Container is required; leaving Encoder empty
+// lets ffmpeg pick its default codec for that container, and leaving both
+// UseBitrate and UseQuality false lets ffmpeg pick its own default rate
+// control for the chosen encoder.
+type EncodeConfig struct {
+	Encoder    string // ffmpeg codec name, e.g. "libmp3lame", "libopus", "aac", "flac", "pcm_s16le"
+	UseBitrate bool
+	Bitrate    string // e.g. "192k", passed as -b:a
+	UseQuality bool
+	Quality    string // e.g. "2" (LAME VBR) or "5" (-q:a for libvorbis/libopus)
+	SampleRate int    // Hz; 0 leaves ffmpeg's default
+	Channels   int    // 0 leaves ffmpeg's default
+	Container  string // output container/extension, e.g. "mp3", "opus", "wav"
+}
+
+// encoderContainers lists, for each supported encoder, the containers it's
+// known to work in. It's not exhaustive of everything ffmpeg supports - it
+// exists to catch obviously mismatched combinations (e.g. flac into a wav
+// file) before spending time on a decode and detection pass.
+var encoderContainers = map[string][]string{
+	"libmp3lame": {"mp3"},
+	"libopus":    {"opus", "ogg"},
+	"libvorbis":  {"ogg"},
+	"aac":        {"m4a", "aac"},
+	"flac":       {"flac"},
+	"pcm_s16le":  {"wav"},
+}
+
+// validate checks that cfg describes an ffmpeg invocation that makes sense
+// on paper: a container is set, bitrate/quality aren't both requested, and
+// (when the encoder is one we know about) the encoder and container agree.
+func (cfg EncodeConfig) validate() error {
+	if cfg.Container == "" {
+		return fmt.Errorf("encode config: container is required")
+	}
+	if cfg.UseBitrate && cfg.UseQuality {
+		return fmt.Errorf("encode config: cannot set both UseBitrate and UseQuality")
+	}
+	if cfg.Encoder == "" {
+		return nil
+	}
+
+	containers, known := encoderContainers[cfg.Encoder]
+	if !known {
+		return nil
+	}
+	for _, c := range containers {
+		if c == cfg.Container {
+			return nil
+		}
+	}
+	return fmt.Errorf("encode config: encoder %q is not known to support container %q (expected one of: %s)", cfg.Encoder, cfg.Container, strings.Join(containers, ", "))
+}
+
+// ffmpegArgs builds the "-c:a ... -b:a/-q:a ... -ar ... -ac ..." arguments
+// ffmpeg needs to encode the export according to cfg. It doesn't pass an
+// explicit "-f" container/muxer: Container names a file extension (e.g.
+// "m4a"), not necessarily the matching ffmpeg muxer short name (e.g.
+// "ipod"), so the muxer is left for ffmpeg to infer from the output
+// filename's extension, which the caller builds from Container already.
+func (cfg EncodeConfig) ffmpegArgs() []string {
+	var args []string
+
+	if cfg.Encoder != "" {
+		args = append(args, "-c:a", cfg.Encoder)
+	}
+
+	switch {
+	case cfg.UseBitrate && cfg.Bitrate != "":
+		args = append(args, "-b:a", cfg.Bitrate)
+	case cfg.UseQuality && cfg.Quality != "":
+		args = append(args, "-q:a", cfg.Quality)
+	}
+
+	if cfg.SampleRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(cfg.SampleRate))
+	}
+	if cfg.Channels > 0 {
+		args = append(args, "-ac", strconv.Itoa(cfg.Channels))
+	}
+
+	return args
+}
@@ -0,0 +1,90 @@
+package vad
+
+// Peak holds the minimum and maximum (signed) sample value observed within a
+// single waveform bin.
+type Peak struct {
+	Min float32 `json:"min"`
+	Max float32 `json:"max"`
+}
+
+// Segment marks a detected region of speech, in seconds from the start of
+// the file.
+type Segment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// ComputePeaks divides samples into numBins bins and returns the min/max
+// sample value seen in each, suitable for rendering a waveform without
+// transferring every sample to the UI.
+func ComputePeaks(samples []float32, numBins int) []Peak {
+	updates := make(chan Peak, numBins)
+	go ComputePeaksStream(samples, numBins, updates)
+
+	peaks := make([]Peak, 0, numBins)
+	for p := range updates {
+		peaks = append(peaks, p)
+	}
+	return peaks
+}
+
+// ComputePeaksStream behaves like ComputePeaks but emits each bin's Peak on
+// peaks as soon as it's computed, closing the channel when done. This lets
+// callers (e.g. the Wails UI) render a waveform progressively while a long
+// file is still being analyzed.
+func ComputePeaksStream(samples []float32, numBins int, peaks chan<- Peak) {
+	defer close(peaks)
+
+	if numBins <= 0 || len(samples) == 0 {
+		return
+	}
+
+	framesPerBin := len(samples) / numBins
+	if framesPerBin == 0 {
+		framesPerBin = 1
+	}
+
+	for bin := 0; bin < numBins; bin++ {
+		start := bin * framesPerBin
+		if start >= len(samples) {
+			peaks <- Peak{}
+			continue
+		}
+
+		end := start + framesPerBin
+		if bin == numBins-1 || end > len(samples) {
+			end = len(samples)
+		}
+
+		min, max := samples[start], samples[start]
+		for _, s := range samples[start+1 : end] {
+			if s < min {
+				min = s
+			}
+			if s > max {
+				max = s
+			}
+		}
+		peaks <- Peak{Min: min, Max: max}
+	}
+}
+
+// DetectSegments decodes inFile and runs silero speech detection over it,
+// without producing an exported audio file. It's the analysis half of
+// CondenseWithOptions, split out so callers can preview detected speech
+// regions (and render a waveform from the same decode) before committing to
+// an export.
+func DetectSegments(inFile string, threshold float32, minSilenceDuration, padMs int, progressCallback ProgressCallback) ([]float32, []Segment, error) {
+	if progressCallback == nil {
+		progressCallback = func(ProgressUpdate) {}
+	}
+	return detectSpeech(inFile, threshold, minSilenceDuration, padMs, progressCallback, func(string) {})
+}
+
+// DetectSegmentsFromSamples re-runs speech detection on samples already
+// decoded by a previous DetectSegments call, so callers tuning VAD
+// thresholds interactively (e.g. a waveform preview) don't have to pay for
+// another ffmpeg decode on every change.
+func DetectSegmentsFromSamples(samples []float32, threshold float32, minSilenceDuration, padMs int) ([]Segment, error) {
+	return detectOnSamples(samples, threshold, minSilenceDuration, padMs, nil)
+}
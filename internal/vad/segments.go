@@ -0,0 +1,118 @@
+package vad
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WriteSegments writes segments to path as a sidecar file in the given
+// format:
+//
+//   - "json": an array of {start,end,duration} objects, in seconds
+//   - "csv":  "start,end" rows with a header
+//   - "srt":  numbered subtitle cues with a "[speech]" placeholder line
+//   - "edl":  a tab-separated start/end/label track, compatible with
+//     Audacity and Premiere label tracks
+func WriteSegments(segments []Segment, path, format string) error {
+	switch format {
+	case "json":
+		return writeSegmentsJSON(segments, path)
+	case "csv":
+		return writeSegmentsCSV(segments, path)
+	case "srt":
+		return writeSegmentsSRT(segments, path)
+	case "edl":
+		return writeSegmentsEDL(segments, path)
+	default:
+		return fmt.Errorf("unsupported segments format: %q", format)
+	}
+}
+
+func writeSegmentsJSON(segments []Segment, path string) error {
+	type jsonSegment struct {
+		Start    float64 `json:"start"`
+		End      float64 `json:"end"`
+		Duration float64 `json:"duration"`
+	}
+
+	out := make([]jsonSegment, len(segments))
+	for i, s := range segments {
+		out[i] = jsonSegment{Start: s.Start, End: s.End, Duration: s.End - s.Start}
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal segments: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeSegmentsCSV(segments []Segment, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"start", "end"}); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+	for _, s := range segments {
+		if err := w.Write([]string{formatSeconds(s.Start), formatSeconds(s.End)}); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeSegmentsSRT(segments []Segment, path string) error {
+	var b strings.Builder
+	for i, s := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n[speech]\n\n", i+1, formatSRTTimestamp(s.Start), formatSRTTimestamp(s.End))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeSegmentsEDL(segments []Segment, path string) error {
+	var b strings.Builder
+	for i, s := range segments {
+		fmt.Fprintf(&b, "%s\t%s\tspeech-%d\n", formatSeconds(s.Start), formatSeconds(s.End), i+1)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// formatSeconds formats seconds to millisecond precision for plain-text
+// sidecar formats (csv, edl).
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+// formatSRTTimestamp formats seconds as an SRT "HH:MM:SS,mmm" timestamp.
+func formatSRTTimestamp(seconds float64) string {
+	if seconds < 0 {
+		seconds = 0
+	}
+	totalMs := int64(seconds*1000 + 0.5)
+	ms := totalMs % 1000
+	totalSec := totalMs / 1000
+	s := totalSec % 60
+	totalMin := totalSec / 60
+	m := totalMin % 60
+	h := totalMin / 60
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
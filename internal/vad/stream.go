@@ -0,0 +1,191 @@
+package vad
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strings"
+
+	"github.com/jjsteffen/silero-vad-go/speech"
+)
+
+// chunkSeconds bounds how much decoded PCM is held in memory at once. A
+// 2-hour podcast at 16kHz mono would otherwise buffer ~460MB of float32
+// samples before detection even started.
+const chunkSeconds = 30
+const chunkSamples = chunkSeconds * sampleRate
+
+// boundaryEpsilon is how close (in seconds) a chunk-local segment's start
+// has to be to the previous chunk's last segment's end before the two are
+// treated as one speech region that happened to straddle the chunk split.
+const boundaryEpsilon = 0.05
+
+// chunkedDetector runs silero detection one chunk at a time and stitches the
+// per-chunk segments into a single timeline, merging a trailing in-speech
+// region with a leading one in the next chunk when they're adjacent (see
+// boundaryEpsilon). detectSpeechChunked (streaming a file off disk for
+// export) and detectOnSamples (chunking an already-decoded buffer for
+// preview) both feed chunks through the same detector, so the segments a
+// user previews match what CondenseWithOptions actually exports.
+type chunkedDetector struct {
+	sd       *speech.Detector
+	segments []Segment
+}
+
+// detectChunk runs detection on chunk, whose first sample is chunkStart
+// seconds into the file, appending to (or extending) d.segments.
+func (d *chunkedDetector) detectChunk(chunkStart float64, chunk []float32) error {
+	rawSegments, err := d.sd.Detect(chunk)
+	if err != nil {
+		return fmt.Errorf("speech detection failed on chunk starting at %.2fs: %w", chunkStart, err)
+	}
+
+	for _, s := range rawSegments {
+		seg := Segment{Start: chunkStart + s.SpeechStartAt, End: chunkStart + s.SpeechEndAt}
+		if n := len(d.segments); n > 0 && seg.Start-d.segments[n-1].End <= boundaryEpsilon {
+			d.segments[n-1].End = seg.End
+			continue
+		}
+		d.segments = append(d.segments, seg)
+	}
+	return nil
+}
+
+// streamDecode opens ffmpeg against inFile and invokes onChunk with
+// successive chunks of up to chunkSamples decoded float32 samples, without
+// ever holding the fully decoded file in memory. Progress is reported
+// against the "decode" stage using totalSeconds (from probeDuration).
+func streamDecode(inFile string, totalSeconds float64, progressCallback ProgressCallback, onChunk func(chunk []float32) error) error {
+	args := []string{
+		"-i", inFile,
+		"-f", "f32le", // 32-bit float, little-endian
+		"-acodec", "pcm_f32le",
+		"-ac", "1", // mono
+		"-ar", fmt.Sprintf("%d", sampleRate),
+		"-progress", "pipe:2",
+		"-nostats",
+		"-", // output to stdout
+	}
+
+	cmd := exec.Command("ffmpeg", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open ffmpeg stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg decode: %w", err)
+	}
+
+	var stderrLog strings.Builder
+	progressDone := make(chan struct{})
+	go func() {
+		scanProgress(stderr, totalSeconds, "decode", progressCallback, &stderrLog)
+		close(progressDone)
+	}()
+
+	const bytesPerSample = 4
+	buf := make([]byte, chunkSamples*bytesPerSample)
+
+	var chunkErr error
+readLoop:
+	for {
+		n, err := io.ReadFull(stdout, buf)
+		if n > 0 {
+			chunk := make([]float32, n/bytesPerSample)
+			for i := range chunk {
+				bits := binary.LittleEndian.Uint32(buf[i*bytesPerSample:])
+				chunk[i] = math.Float32frombits(bits)
+			}
+			if chunkErr = onChunk(chunk); chunkErr != nil {
+				break readLoop
+			}
+		}
+		switch err {
+		case nil:
+			continue
+		case io.EOF, io.ErrUnexpectedEOF:
+			break readLoop
+		default:
+			chunkErr = fmt.Errorf("failed to read decoded audio: %w", err)
+			break readLoop
+		}
+	}
+
+	<-progressDone
+
+	if waitErr := cmd.Wait(); waitErr != nil {
+		return fmt.Errorf("ffmpeg decode error: %w, stderr: %s", waitErr, stderrLog.String())
+	}
+	return chunkErr
+}
+
+// detectSpeechChunked decodes inFile in fixed-size chunks and runs speech
+// detection incrementally, so a long file never needs its entire decoded
+// PCM held in memory at once. Segment timestamps are stitched across chunk
+// boundaries by adding a running chunk offset, merging a trailing in-speech
+// region with a leading one in the next chunk when they're adjacent.
+func detectSpeechChunked(inFile string, threshold float32, minSilenceDuration, padMs int, progressCallback ProgressCallback, statusCallback StatusCallback) ([]Segment, error) {
+	inDuration, err := probeDuration(inFile)
+	if err != nil {
+		slog.Warn("Failed to probe input duration, progress reporting will be unavailable", "file", inFile, "error", err)
+	}
+
+	statusCallback("loading")
+
+	sd, err := speech.NewDetector(speech.DetectorConfig{
+		ModelPath:            "./silero_vad.onnx",
+		SampleRate:           sampleRate,
+		Threshold:            threshold,
+		MinSilenceDurationMs: minSilenceDuration,
+		SpeechPadMs:          padMs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech detector: %w", err)
+	}
+	defer sd.Destroy()
+
+	statusCallback("detecting")
+	slog.Info("Detecting speech segments", "file", inFile)
+	// Silence library debug logs during detection
+	originalDefault := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalDefault)
+
+	detector := chunkedDetector{sd: sd}
+	chunkIndex := 0
+	err = streamDecode(inFile, inDuration, progressCallback, func(chunk []float32) error {
+		chunkStart := float64(chunkIndex) * chunkSeconds
+		chunkIndex++
+
+		if err := detector.detectChunk(chunkStart, chunk); err != nil {
+			return err
+		}
+
+		if inDuration > 0 {
+			chunkEnd := chunkStart + float64(len(chunk))/sampleRate
+			progressCallback(ProgressUpdate{Stage: "detect", Fraction: clampFraction(chunkEnd / inDuration)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	progressCallback(ProgressUpdate{Stage: "detect", Fraction: 1})
+
+	if len(detector.segments) == 0 {
+		slog.Warn("No speech detected", "file", inFile)
+		return nil, fmt.Errorf("no speech detected in file: %s", inFile)
+	}
+
+	slog.Debug("Speech segments detected", "count", len(detector.segments))
+	return detector.segments, nil
+}
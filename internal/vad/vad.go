@@ -1,24 +1,150 @@
 package vad
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/jjsteffen/silero-vad-go/speech"
 )
 
+// sampleRate is the rate (Hz) that audio is decoded to before silero runs
+// detection on it; it's what the VAD model was trained on.
+const sampleRate = 16000
+
 // StatusCallback is called at each stage of the conversion process
 type StatusCallback func(status string)
 
+// ProgressUpdate reports fractional progress for a single stage of the
+// condense pipeline (decoding the input, detecting speech, or exporting
+// the result).
+type ProgressUpdate struct {
+	Stage    string
+	Fraction float64
+}
+
+// ProgressCallback is called with fine-grained, stage-tagged progress as
+// CondenseWithOptions works through a file.
+type ProgressCallback func(ProgressUpdate)
+
+// probeDuration uses ffprobe to determine the duration of inFile, in seconds.
+func probeDuration(inFile string) (float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_format", inFile)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe error: %w", err)
+	}
+
+	var probe struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration %q: %w", probe.Format.Duration, err)
+	}
+	return duration, nil
+}
+
+// clampFraction clamps f to the [0,1] range.
+func clampFraction(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// parseFfmpegTimestamp parses an ffmpeg "HH:MM:SS.xxx" timestamp into seconds.
+func parseFfmpegTimestamp(ts string) (float64, error) {
+	parts := strings.Split(ts, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	hours, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+	}
+	minutes, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+	}
+	return hours*3600 + minutes*60 + seconds, nil
+}
+
+// parseFfmpegProgressFraction parses a single line of ffmpeg's "-progress"
+// output, returning the fraction of totalSeconds completed so far. ok is
+// false for lines that don't carry a time value (e.g. "bitrate="), and for
+// every line when totalSeconds isn't positive.
+func parseFfmpegProgressFraction(line string, totalSeconds float64) (fraction float64, ok bool) {
+	if totalSeconds <= 0 {
+		return 0, false
+	}
+
+	if v, found := strings.CutPrefix(line, "out_time_ms="); found {
+		// Despite the name, ffmpeg reports this value in microseconds.
+		micros, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return clampFraction(float64(micros) / 1_000_000 / totalSeconds), true
+	}
+
+	if v, found := strings.CutPrefix(line, "out_time="); found {
+		seconds, err := parseFfmpegTimestamp(strings.TrimSpace(v))
+		if err != nil {
+			return 0, false
+		}
+		return clampFraction(seconds / totalSeconds), true
+	}
+
+	return 0, false
+}
+
+// scanProgress reads ffmpeg's "-progress pipe:2" stream from r, emitting a
+// ProgressUpdate for the given stage as each out_time value is seen. Every
+// other line except "progress=end" (including ffmpeg's remaining progress
+// keys) is collected into log so callers can include it in error messages.
+func scanProgress(r io.Reader, totalSeconds float64, stage string, progressCallback ProgressCallback, log *strings.Builder) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if fraction, ok := parseFfmpegProgressFraction(line, totalSeconds); ok {
+			progressCallback(ProgressUpdate{Stage: stage, Fraction: fraction})
+			continue
+		}
+		if line == "progress=end" {
+			progressCallback(ProgressUpdate{Stage: stage, Fraction: 1})
+			continue
+		}
+		log.WriteString(line)
+		log.WriteString("\n")
+	}
+}
+
 // decodeToFloat32 uses ffmpeg to decode any audio format to raw PCM float32 samples.
 // Returns the samples as []float32 at the specified sample rate (mono).
-func decodeToFloat32(inFile string, sampleRate int) ([]float32, error) {
+// totalSeconds (from probeDuration) drives the "decode" stage progress updates.
+func decodeToFloat32(inFile string, sampleRate int, totalSeconds float64, progressCallback ProgressCallback) ([]float32, error) {
 	slog.Debug("Decoding audio file", "file", inFile, "sampleRate", sampleRate)
 
 	args := []string{
@@ -27,17 +153,29 @@ func decodeToFloat32(inFile string, sampleRate int) ([]float32, error) {
 		"-acodec", "pcm_f32le",
 		"-ac", "1", // mono
 		"-ar", fmt.Sprintf("%d", sampleRate),
+		"-progress", "pipe:2",
+		"-nostats",
 		"-", // output to stdout
 	}
 
 	cmd := exec.Command("ffmpeg", args...)
 	var stdout bytes.Buffer
-	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("ffmpeg decode error: %w, stderr: %s", err, stderr.String())
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg decode: %w", err)
+	}
+
+	var stderrLog strings.Builder
+	scanProgress(stderr, totalSeconds, "decode", progressCallback, &stderrLog)
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg decode error: %w, stderr: %s", err, stderrLog.String())
 	}
 
 	// Parse raw float32 samples from stdout
@@ -59,67 +197,114 @@ func decodeToFloat32(inFile string, sampleRate int) ([]float32, error) {
 	return samples, nil
 }
 
-// CondenseWithOptions processes a single audio file and removes silence.
-// Supports any audio format that ffmpeg can decode (mp3, wav, flac, m4a, ogg, etc.)
-// The progressCallback and statusCallback parameters are optional - pass nil for silent operation.
-func CondenseWithOptions(inFile, outDir, outSuffix, outFormat string, threshold float32, minSilenceDuration, padMs int, progressCallback func(float64), statusCallback StatusCallback) error {
-	const sampleRate = 16000
-
-	slog.Debug("Options", "threshold", threshold, "minSilenceDuration", minSilenceDuration, "padMs", padMs, "outFormat", outFormat)
-
-	// Use a no-op callback if none provided
-	if progressCallback == nil {
-		progressCallback = func(float64) {}
-	}
-	if statusCallback == nil {
-		statusCallback = func(string) {}
+// detectSpeech decodes inFile in full and runs silero speech detection over
+// it, returning the decoded samples (so callers that also need a waveform
+// don't have to decode twice) and the detected segments, in seconds.
+// statusCallback receives "loading" then "detecting" as the two stages run.
+// It holds the whole file's PCM in memory; CondenseWithOptions uses the
+// chunked detectSpeechChunked instead so long files don't require that.
+func detectSpeech(inFile string, threshold float32, minSilenceDuration, padMs int, progressCallback ProgressCallback, statusCallback StatusCallback) ([]float32, []Segment, error) {
+	inDuration, err := probeDuration(inFile)
+	if err != nil {
+		slog.Warn("Failed to probe input duration, progress reporting will be unavailable", "file", inFile, "error", err)
 	}
 
-	// Decode input file to raw float32 samples using ffmpeg
 	statusCallback("loading")
-	samples, err := decodeToFloat32(inFile, sampleRate)
+	samples, err := decodeToFloat32(inFile, sampleRate, inDuration, progressCallback)
 	if err != nil {
-		return fmt.Errorf("failed to decode audio file: %w", err)
+		return nil, nil, fmt.Errorf("failed to decode audio file: %w", err)
 	}
 
 	statusCallback("detecting")
+	segments, err := detectOnSamples(samples, threshold, minSilenceDuration, padMs, progressCallback)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", err, inFile)
+	}
+	return samples, segments, nil
+}
+
+// detectOnSamples runs silero speech detection on already-decoded samples,
+// chunked the same way detectSpeechChunked chunks a file being streamed off
+// disk (see chunkedDetector), so the segments a caller previews here match
+// what CondenseWithOptions would actually export for the same samples. It's
+// split out from detectSpeech so callers that already hold decoded PCM (e.g.
+// to re-run detection with a different threshold) don't have to pay for
+// another ffmpeg decode.
+func detectOnSamples(samples []float32, threshold float32, minSilenceDuration, padMs int, progressCallback ProgressCallback) ([]Segment, error) {
+	if progressCallback == nil {
+		progressCallback = func(ProgressUpdate) {}
+	}
+
 	sd, err := speech.NewDetector(speech.DetectorConfig{
 		ModelPath:            "./silero_vad.onnx",
 		SampleRate:           sampleRate,
 		Threshold:            threshold,
 		MinSilenceDurationMs: minSilenceDuration,
 		SpeechPadMs:          padMs,
-		ProgressCallback:     progressCallback,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create speech detector: %w", err)
+		return nil, fmt.Errorf("failed to create speech detector: %w", err)
 	}
 	defer sd.Destroy()
 
-	slog.Info("Detecting speech segments", "file", inFile)
+	slog.Info("Detecting speech segments", "samples", len(samples))
 	// Silence library debug logs during detection
 	originalDefault := slog.Default()
 	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(originalDefault)
 
-	segments, err := sd.Detect(samples)
+	detector := chunkedDetector{sd: sd}
+	for chunkStart := 0; chunkStart < len(samples); chunkStart += chunkSamples {
+		chunkEnd := chunkStart + chunkSamples
+		if chunkEnd > len(samples) {
+			chunkEnd = len(samples)
+		}
 
-	// Restore original logger
-	slog.SetDefault(originalDefault)
+		if err := detector.detectChunk(float64(chunkStart)/sampleRate, samples[chunkStart:chunkEnd]); err != nil {
+			return nil, err
+		}
+		progressCallback(ProgressUpdate{Stage: "detect", Fraction: clampFraction(float64(chunkEnd) / float64(len(samples)))})
+	}
 
-	if err != nil {
-		return fmt.Errorf("speech detection failed: %w", err)
+	if len(detector.segments) == 0 {
+		slog.Warn("No speech detected")
+		return nil, fmt.Errorf("no speech detected")
 	}
 
-	if len(segments) == 0 {
-		slog.Warn("No speech detected", "file", inFile)
-		return fmt.Errorf("no speech detected in file: %s", inFile)
+	slog.Debug("Speech segments detected", "count", len(detector.segments))
+	return detector.segments, nil
+}
+
+// CondenseWithOptions processes a single audio file and removes silence.
+// Supports any audio format that ffmpeg can decode (mp3, wav, flac, m4a, ogg, etc.)
+// If segmentsFormat is non-empty, the detected speech segments are also
+// written as a sidecar file next to the condensed audio (see WriteSegments).
+// The progressCallback and statusCallback parameters are optional - pass nil for silent operation.
+func CondenseWithOptions(inFile, outDir, outSuffix string, encodeConfig EncodeConfig, segmentsFormat, segmentsSuffix string, threshold float32, minSilenceDuration, padMs int, progressCallback ProgressCallback, statusCallback StatusCallback) error {
+	slog.Debug("Options", "threshold", threshold, "minSilenceDuration", minSilenceDuration, "padMs", padMs, "encodeConfig", encodeConfig)
+
+	if err := encodeConfig.validate(); err != nil {
+		return err
 	}
 
-	slog.Debug("Speech segments detected", "count", len(segments))
+	// Use a no-op callback if none provided
+	if progressCallback == nil {
+		progressCallback = func(ProgressUpdate) {}
+	}
+	if statusCallback == nil {
+		statusCallback = func(string) {}
+	}
+
+	segments, err := detectSpeechChunked(inFile, threshold, minSilenceDuration, padMs, progressCallback, statusCallback)
+	if err != nil {
+		return err
+	}
 
 	cuts := make([]string, len(segments))
+	var outDuration float64
 	for i, s := range segments {
-		cuts[i] = fmt.Sprintf("between(t,%.2f,%.2f)", s.SpeechStartAt, s.SpeechEndAt)
+		cuts[i] = fmt.Sprintf("between(t,%.2f,%.2f)", s.Start, s.End)
+		outDuration += s.End - s.Start
 	}
 
 	af := fmt.Sprintf("aselect='%s',asetpts=N/SR/TB", strings.Join(cuts, "+"))
@@ -132,7 +317,7 @@ func CondenseWithOptions(inFile, outDir, outSuffix, outFormat string, threshold
 		outDir = inDir
 	}
 
-	outName := filepath.Join(outDir, strings.TrimSuffix(baseName, ext)+outSuffix+"."+outFormat)
+	outName := filepath.Join(outDir, strings.TrimSuffix(baseName, ext)+outSuffix+"."+encodeConfig.Container)
 
 	slog.Debug("Creating output file", "file", outName)
 
@@ -143,13 +328,35 @@ func CondenseWithOptions(inFile, outDir, outSuffix, outFormat string, threshold
 		"-i", inFile,
 		"-vn",
 		"-af", af,
-		outName,
+		"-progress", "pipe:2",
+		"-nostats",
 	}
+	args = append(args, encodeConfig.ffmpegArgs()...)
+	args = append(args, outName)
 
 	cmd := exec.Command("ffmpeg", args...)
-	output, err := cmd.CombinedOutput()
+	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("ffmpeg error: %w, output: %s", err, string(output))
+		return fmt.Errorf("failed to open ffmpeg stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg export: %w", err)
+	}
+
+	var stderrLog strings.Builder
+	scanProgress(stderr, outDuration, "export", progressCallback, &stderrLog)
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg error: %w, output: %s", err, stderrLog.String())
+	}
+
+	if segmentsFormat != "" {
+		segmentsName := filepath.Join(outDir, strings.TrimSuffix(baseName, ext)+segmentsSuffix+"."+segmentsFormat)
+		if err := WriteSegments(segments, segmentsName, segmentsFormat); err != nil {
+			return fmt.Errorf("failed to write segments sidecar: %w", err)
+		}
+		slog.Debug("Wrote segments sidecar", "file", segmentsName)
 	}
 
 	slog.Info("File processed successfully", "input", inFile, "output", outName)